@@ -28,6 +28,24 @@ type Importer struct {
 	batch     db.Batch
 	batchSize uint32
 	stack     []*Node
+
+	// itemsConsumed counts ExportNodes passed to Add, so a checkpoint can tell a resuming
+	// caller how many nodes of its source stream it may skip.
+	itemsConsumed int64
+
+	// fastNodesConsumed counts the fast-node index entries Add has written alongside
+	// itemsConsumed, i.e. how many of those ExportNodes were leaves written under
+	// buildFastIndex. A checkpoint needs this separately from itemsConsumed because each one
+	// adds an extra key - the fast-node entry - beside the regular node write.
+	fastNodesConsumed int64
+
+	// expectedRootHash is set by ImportWithVerification; if non-nil, Commit verifies the
+	// finished import's root hash against it before writing anything visible.
+	expectedRootHash []byte
+
+	// buildFastIndex is set by ImportWithOptions; if true, Add additionally populates the
+	// fast-node index for every leaf as it is imported.
+	buildFastIndex bool
 }
 
 // newImporter creates a new Importer for an empty MutableTree.
@@ -54,7 +72,8 @@ func newImporter(tree *MutableTree, version int64) (*Importer, error) {
 }
 
 // Close frees all resources. It is safe to call multiple times. Uncommitted nodes may already have
-// been flushed to the database, but will not be visible.
+// been flushed to the database, but will not be visible. Any checkpoint written by a prior flush
+// is left intact, so the import can later be resumed with ResumeImport.
 func (i *Importer) Close() {
 	if i.batch != nil {
 		i.batch.Close()
@@ -78,47 +97,9 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 			exportNode.NodeKey.version, i.version)
 	}
 
-	node := &Node{
-		key:           exportNode.Key,
-		value:         exportNode.Value,
-		nodeKey:       exportNode.NodeKey,
-		subtreeHeight: exportNode.Height,
-	}
-
-	// We build the tree from the bottom-left up. The stack is used to store unresolved left
-	// children while constructing right children. When all children are built, the parent can
-	// be constructed and the resolved children can be discarded from the stack. Using a stack
-	// ensures that we can handle additional unresolved left children while building a right branch.
-	//
-	// We don't modify the stack until we've verified the built node, to avoid leaving the
-	// importer in an inconsistent state when we return an error.
-	stackSize := len(i.stack)
-	switch {
-	case stackSize >= 2 && i.stack[stackSize-1].subtreeHeight < node.subtreeHeight && i.stack[stackSize-2].subtreeHeight < node.subtreeHeight:
-		node.leftNode = i.stack[stackSize-2]
-		node.rightNode = i.stack[stackSize-1]
-	case stackSize >= 1 && i.stack[stackSize-1].subtreeHeight < node.subtreeHeight:
-		node.leftNode = i.stack[stackSize-1]
-	}
-
-	if node.subtreeHeight == 0 {
-		node.size = 1
-	}
-	if node.leftNode != nil {
-		node.size += node.leftNode.size
-		node.leftNodeKey = node.leftNode.nodeKey
-	}
-	if node.rightNode != nil {
-		node.size += node.rightNode.size
-		node.rightNodeKey = node.rightNode.nodeKey
-	}
-
-	_, err := node._hash(exportNode.NodeKey.version)
-	if err != nil {
-		return err
-	}
-
-	err = node.validate()
+	// buildNode resolves exportNode's children from the stack and hashes/validates it, without
+	// touching the stack or the database until we know the node is well-formed.
+	node, newStack, err := buildNode(i.stack, exportNode)
 	if err != nil {
 		return err
 	}
@@ -138,7 +119,25 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 		return err
 	}
 
+	if i.buildFastIndex && node.subtreeHeight == 0 {
+		if err := i.addFastNode(node, exportNode.NodeKey.version); err != nil {
+			return err
+		}
+		i.fastNodesConsumed++
+	}
+
+	// Update the stack now that we know there were no errors
+	i.stack = newStack
+
 	i.batchSize++
+	i.itemsConsumed++
+	// Persist a checkpoint alongside every batch flush, in the batch being flushed, so a crash
+	// mid-import can be resumed from roughly the last maxBatchSize nodes rather than from scratch.
+	if i.batchSize >= maxBatchSize {
+		if err := i.writeCheckpoint(); err != nil {
+			return err
+		}
+	}
 	if i.batchSize >= maxBatchSize {
 		err = i.batch.Write()
 		if err != nil {
@@ -149,41 +148,62 @@ func (i *Importer) Add(exportNode *ExportNode) error {
 		i.batchSize = 0
 	}
 
-	// Update the stack now that we know there were no errors
-	switch {
-	case node.leftNode != nil && node.rightNode != nil:
-		i.stack = i.stack[:stackSize-2]
-	case node.leftNode != nil || node.rightNode != nil:
-		i.stack = i.stack[:stackSize-1]
+	return nil
+}
+
+// Verify checks that the import is in a state Commit can successfully finalize: the stack holds
+// either nothing (an empty tree) or a single resolved root, and, if the importer was created via
+// ImportWithVerification, that the root hash matches the expected hash. It performs no writes,
+// which lets a caller driving several Importers - e.g. one per store in a multi-store restore -
+// verify all of them before committing any, so a failure in one doesn't leave the others
+// committed.
+func (i *Importer) Verify() error {
+	if i.tree == nil {
+		return ErrNoImport
+	}
+
+	switch len(i.stack) {
+	case 0, 1:
+	default:
+		return fmt.Errorf("invalid node structure, found stack size %v when committing",
+			len(i.stack))
+	}
+
+	if i.expectedRootHash != nil {
+		if !bytes.Equal(i.RootHash(), i.expectedRootHash) {
+			return ErrRootMismatch
+		}
 	}
-	// Only hash\height\size of the node will be used after it be pushed into the stack.
-	i.stack = append(i.stack, &Node{hash: node.hash, subtreeHeight: node.subtreeHeight, size: node.size, nodeKey: node.nodeKey})
 
 	return nil
 }
 
 // Commit finalizes the import by flushing any outstanding nodes to the database, making the
 // version visible, and updating the tree metadata. It can only be called once, and calls Close()
-// internally.
+// internally. Any checkpoint written for a resumable import is cleared as part of the same batch.
 func (i *Importer) Commit() error {
-	if i.tree == nil {
-		return ErrNoImport
+	if err := i.Verify(); err != nil {
+		return err
 	}
 
-	rootKey := &NodeKey{
-		version: i.version,
-		path:    big.NewInt(1),
-	}
-	switch len(i.stack) {
-	case 0:
+	if len(i.stack) == 0 {
+		rootKey := &NodeKey{
+			version: i.version,
+			path:    big.NewInt(1),
+		}
 		if err := i.batch.Set(i.tree.ndb.nodeKey(rootKey), []byte{}); err != nil {
 			return err
 		}
-	case 1:
+	}
 
-	default:
-		return fmt.Errorf("invalid node structure, found stack size %v when committing",
-			len(i.stack))
+	if err := i.batch.Delete(checkpointKey); err != nil {
+		return err
+	}
+
+	if i.buildFastIndex {
+		if err := i.tree.ndb.setFastStorageVersionToBatch(i.batch); err != nil {
+			return err
+		}
 	}
 
 	err := i.batch.WriteSync()
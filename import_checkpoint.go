@@ -0,0 +1,227 @@
+package iavl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	db "github.com/cosmos/cosmos-db"
+)
+
+// checkpointKey is the reserved ndb key under which an in-progress Importer periodically
+// persists its stack, so a crash mid-import doesn't force a full restart of a (potentially
+// multi-GB) state sync. It is cleared by Commit and left intact by Close.
+var checkpointKey = []byte{0x90}
+
+// writeCheckpoint serializes the importer's current stack and options into the batch that is about
+// to be flushed, so the checkpoint reaches disk together with the nodes it describes and
+// ResumeImport can fully reconstruct an equivalent Importer, including one created via
+// ImportWithOptions or ImportWithVerification.
+func (i *Importer) writeCheckpoint() error {
+	data := encodeCheckpoint(i.version, i.itemsConsumed, i.fastNodesConsumed, i.buildFastIndex, i.expectedRootHash, i.stack)
+	return i.batch.Set(checkpointKey, data)
+}
+
+func encodeCheckpoint(version, itemsConsumed, fastNodesConsumed int64, buildFastIndex bool, expectedRootHash []byte, stack []*Node) []byte {
+	var buf []byte
+	buf = appendVarint(buf, uint64(version))
+	buf = appendVarint(buf, uint64(itemsConsumed))
+	buf = appendVarint(buf, uint64(fastNodesConsumed))
+	if buildFastIndex {
+		buf = appendVarint(buf, 1)
+	} else {
+		buf = appendVarint(buf, 0)
+	}
+	buf = appendVarint(buf, uint64(len(expectedRootHash)))
+	buf = append(buf, expectedRootHash...)
+	buf = appendVarint(buf, uint64(len(stack)))
+	for _, node := range stack {
+		buf = appendVarint(buf, uint64(node.subtreeHeight))
+		buf = appendVarint(buf, uint64(node.size))
+		buf = appendVarint(buf, uint64(len(node.hash)))
+		buf = append(buf, node.hash...)
+		buf = appendVarint(buf, uint64(node.nodeKey.version))
+		path := node.nodeKey.path.Bytes()
+		buf = appendVarint(buf, uint64(len(path)))
+		buf = append(buf, path...)
+	}
+	return buf
+}
+
+func decodeCheckpoint(data []byte) (version, itemsConsumed, fastNodesConsumed int64, buildFastIndex bool, expectedRootHash []byte, stack []*Node, err error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad version")
+	}
+	version = int64(v)
+	data = data[n:]
+
+	v, n = binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad items-consumed counter")
+	}
+	itemsConsumed = int64(v)
+	data = data[n:]
+
+	v, n = binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad fast-nodes-consumed counter")
+	}
+	fastNodesConsumed = int64(v)
+	data = data[n:]
+
+	v, n = binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad build-fast-index flag")
+	}
+	buildFastIndex = v != 0
+	data = data[n:]
+
+	rootHashLen, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad expected-root-hash length")
+	}
+	data = data[n:]
+	if rootHashLen > 0 {
+		if uint64(len(data)) < rootHashLen {
+			return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: truncated expected-root-hash")
+		}
+		expectedRootHash = make([]byte, rootHashLen)
+		copy(expectedRootHash, data[:rootHashLen])
+		data = data[rootHashLen:]
+	}
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad stack length")
+	}
+	data = data[n:]
+
+	stack = make([]*Node, 0, count)
+	for idx := uint64(0); idx < count; idx++ {
+		height, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad stack entry %d", idx)
+		}
+		data = data[n:]
+
+		size, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad stack entry %d", idx)
+		}
+		data = data[n:]
+
+		hashLen, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad stack entry %d", idx)
+		}
+		data = data[n:]
+		hash := make([]byte, hashLen)
+		copy(hash, data[:hashLen])
+		data = data[hashLen:]
+
+		nodeVersion, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad stack entry %d", idx)
+		}
+		data = data[n:]
+
+		pathLen, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, 0, 0, false, nil, nil, fmt.Errorf("corrupt checkpoint: bad stack entry %d", idx)
+		}
+		data = data[n:]
+		path := make([]byte, pathLen)
+		copy(path, data[:pathLen])
+		data = data[pathLen:]
+
+		stack = append(stack, &Node{
+			hash:          hash,
+			subtreeHeight: int8(height),
+			size:          int64(size),
+			nodeKey:       &NodeKey{version: int64(nodeVersion), path: new(big.Int).SetBytes(path)},
+		})
+	}
+
+	return version, itemsConsumed, fastNodesConsumed, buildFastIndex, expectedRootHash, stack, nil
+}
+
+// ResumeImport reloads a checkpoint previously written by a crashed or interrupted Importer for
+// the given version and returns a ready-to-use Importer along with the number of ExportNodes
+// already applied, so the caller can skip ahead in its source stream rather than re-adding nodes
+// it already has on disk. The returned Importer carries forward whatever ImportOptions or
+// expected root hash the original Importer was created with, so resuming an ImportWithOptions or
+// ImportWithVerification import behaves exactly as if it had never been interrupted.
+//
+// It rejects resuming if the database doesn't look like the untouched tail of an aborted import:
+// a nonzero latestVersion means a prior import already committed, a missing checkpoint means there
+// is nothing to resume, and a key count that doesn't match the checkpoint means the database has
+// been altered since the crash, e.g. by a stray key injected alongside a forged checkpoint.
+func (tree *MutableTree) ResumeImport(version int64) (*Importer, int64, error) {
+	if tree.ndb.latestVersion != 0 {
+		return nil, 0, fmt.Errorf("found database at version %d, must be 0 to resume an import", tree.ndb.latestVersion)
+	}
+
+	data, err := tree.ndb.db.Get(checkpointKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read import checkpoint: %w", err)
+	}
+	if data == nil {
+		return nil, 0, fmt.Errorf("no import checkpoint found for version %d", version)
+	}
+
+	checkpointVersion, itemsConsumed, fastNodesConsumed, buildFastIndex, expectedRootHash, stack, err := decodeCheckpoint(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if checkpointVersion != version {
+		return nil, 0, fmt.Errorf("checkpoint is for version %d, not %d", checkpointVersion, version)
+	}
+
+	if err := verifyCheckpointKeys(tree.ndb.db, itemsConsumed, fastNodesConsumed); err != nil {
+		return nil, 0, err
+	}
+
+	importer, err := newImporter(tree, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	importer.stack = stack
+	importer.itemsConsumed = itemsConsumed
+	importer.fastNodesConsumed = fastNodesConsumed
+	importer.buildFastIndex = buildFastIndex
+	importer.expectedRootHash = expectedRootHash
+
+	return importer, itemsConsumed, nil
+}
+
+// verifyCheckpointKeys guards ResumeImport against a tampered database. writeCheckpoint always
+// lands the checkpoint in the same batch as the node keys it describes, so an untouched aborted
+// import has exactly itemsConsumed+fastNodesConsumed keys besides checkpointKey - one per node,
+// plus one more per leaf written under ImportOptions.BuildFastIndex. Any more - e.g. a stray key
+// planted alongside a forged checkpoint - or any fewer - e.g. keys deleted out from under a
+// genuine checkpoint - and the database is not the tail of the import it claims to be.
+func verifyCheckpointKeys(database db.DB, itemsConsumed, fastNodesConsumed int64) error {
+	iter, err := database.Iterator(nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to verify import checkpoint: %w", err)
+	}
+	defer iter.Close()
+
+	var count int64
+	for ; iter.Valid(); iter.Next() {
+		if !bytes.Equal(iter.Key(), checkpointKey) {
+			count++
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to verify import checkpoint: %w", err)
+	}
+
+	want := itemsConsumed + fastNodesConsumed
+	if count != want {
+		return fmt.Errorf("refusing to resume: database has %d non-checkpoint keys, checkpoint expects %d; database may have been tampered with", count, want)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package iavl
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	db "github.com/cosmos/cosmos-db"
+)
+
+func TestCheckpointEncodeDecodeRoundtrip(t *testing.T) {
+	stack := []*Node{
+		{
+			hash:          []byte("hash"),
+			subtreeHeight: 2,
+			size:          5,
+			nodeKey:       &NodeKey{version: 3, path: big.NewInt(7)},
+		},
+	}
+
+	data := encodeCheckpoint(9, 42, 7, true, []byte("expected-hash"), stack)
+
+	version, itemsConsumed, fastNodesConsumed, buildFastIndex, expectedRootHash, gotStack, err := decodeCheckpoint(data)
+	if err != nil {
+		t.Fatalf("decodeCheckpoint failed: %v", err)
+	}
+	if version != 9 || itemsConsumed != 42 || fastNodesConsumed != 7 || !buildFastIndex {
+		t.Fatalf("got version=%d itemsConsumed=%d fastNodesConsumed=%d buildFastIndex=%v, want version=9 itemsConsumed=42 fastNodesConsumed=7 buildFastIndex=true",
+			version, itemsConsumed, fastNodesConsumed, buildFastIndex)
+	}
+	if !bytes.Equal(expectedRootHash, []byte("expected-hash")) {
+		t.Fatalf("expectedRootHash roundtrip mismatch: got %q", expectedRootHash)
+	}
+	if len(gotStack) != 1 || string(gotStack[0].hash) != "hash" || gotStack[0].subtreeHeight != 2 ||
+		gotStack[0].size != 5 || gotStack[0].nodeKey.version != 3 || gotStack[0].nodeKey.path.Cmp(big.NewInt(7)) != 0 {
+		t.Fatalf("stack roundtrip mismatch: got %+v", gotStack)
+	}
+}
+
+func TestCheckpointEncodeDecodeRoundtripNoVerificationOrFastIndex(t *testing.T) {
+	data := encodeCheckpoint(1, 2, 0, false, nil, nil)
+
+	_, _, fastNodesConsumed, buildFastIndex, expectedRootHash, _, err := decodeCheckpoint(data)
+	if err != nil {
+		t.Fatalf("decodeCheckpoint failed: %v", err)
+	}
+	if fastNodesConsumed != 0 || buildFastIndex || expectedRootHash != nil {
+		t.Fatalf("got fastNodesConsumed=%d buildFastIndex=%v expectedRootHash=%q, want all zero values",
+			fastNodesConsumed, buildFastIndex, expectedRootHash)
+	}
+}
+
+func TestVerifyCheckpointKeysAcceptsUntouchedCheckpoint(t *testing.T) {
+	database := db.NewMemDB()
+
+	if err := database.Set(checkpointKey, encodeCheckpoint(1, 2, 0, false, nil, nil)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("node-a"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("node-b"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := verifyCheckpointKeys(database, 2, 0); err != nil {
+		t.Fatalf("expected an untouched checkpoint to be accepted, got: %v", err)
+	}
+}
+
+// TestVerifyCheckpointKeysAcceptsFastIndexCheckpoint covers the fast-index interaction the
+// request asked for: a BuildFastIndex import writes one extra key per leaf beyond itemsConsumed,
+// and a genuine, untouched checkpoint for such an import must still be accepted.
+func TestVerifyCheckpointKeysAcceptsFastIndexCheckpoint(t *testing.T) {
+	database := db.NewMemDB()
+
+	if err := database.Set(checkpointKey, encodeCheckpoint(1, 2, 2, true, nil, nil)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("node-a"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("node-b"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("fast-node-a"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("fast-node-b"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := verifyCheckpointKeys(database, 2, 2); err != nil {
+		t.Fatalf("expected an untouched fast-index checkpoint to be accepted, got: %v", err)
+	}
+}
+
+// TestVerifyCheckpointKeysRejectsStrayKey covers the tamper scenario the request asked for: a
+// database holding a valid checkpoint plus an injected key it doesn't account for.
+func TestVerifyCheckpointKeysRejectsStrayKey(t *testing.T) {
+	database := db.NewMemDB()
+
+	if err := database.Set(checkpointKey, encodeCheckpoint(1, 1, 0, false, nil, nil)); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("node-a"), []byte{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := database.Set([]byte("stray-injected-key"), []byte("tampered")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := verifyCheckpointKeys(database, 1, 0); err == nil {
+		t.Fatal("expected a stray key to be rejected, got nil error")
+	}
+}
@@ -0,0 +1,161 @@
+package iavl
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	protoio "github.com/cosmos/gogoproto/io"
+)
+
+// SnapshotIAVLItem is the wire message exchanged by ImportStream/ExportStream. It mirrors
+// cosmos-sdk store/v2's snapshottypes.SnapshotIAVLItem field-for-field so that a
+// CommitSnapshotter can embed an IAVL stream directly, without translating between two node
+// representations.
+type SnapshotIAVLItem struct {
+	Key     []byte
+	Value   []byte
+	Version int64
+	Height  int32
+	Path    []byte
+}
+
+func (m *SnapshotIAVLItem) Reset()       { *m = SnapshotIAVLItem{} }
+func (*SnapshotIAVLItem) String() string { return "SnapshotIAVLItem" }
+func (*SnapshotIAVLItem) ProtoMessage()  {}
+
+// Marshal encodes the item as a minimal protobuf message: fields are tagged 1-5 in declaration
+// order, each as a length-delimited (bytes) or varint record.
+func (m *SnapshotIAVLItem) Marshal() ([]byte, error) {
+	var buf []byte
+	buf = appendBytesField(buf, 1, m.Key)
+	buf = appendBytesField(buf, 2, m.Value)
+	buf = appendVarintField(buf, 3, uint64(m.Version))
+	buf = appendVarintField(buf, 4, uint64(m.Height))
+	buf = appendBytesField(buf, 5, m.Path)
+	return buf, nil
+}
+
+// Unmarshal decodes a buffer produced by Marshal.
+func (m *SnapshotIAVLItem) Unmarshal(data []byte) error {
+	for len(data) > 0 {
+		tag, wireType, n, err := decodeTag(data)
+		if err != nil {
+			return fmt.Errorf("iavl: decoding SnapshotIAVLItem: %w", err)
+		}
+		data = data[n:]
+		switch {
+		case tag == 1 && wireType == 2:
+			m.Key, data, err = decodeBytesField(data)
+		case tag == 2 && wireType == 2:
+			m.Value, data, err = decodeBytesField(data)
+		case tag == 3 && wireType == 0:
+			var v uint64
+			v, data, err = decodeVarintField(data)
+			m.Version = int64(v)
+		case tag == 4 && wireType == 0:
+			var v uint64
+			v, data, err = decodeVarintField(data)
+			m.Height = int32(v)
+		case tag == 5 && wireType == 2:
+			m.Path, data, err = decodeBytesField(data)
+		default:
+			return fmt.Errorf("iavl: unknown field %d in SnapshotIAVLItem", tag)
+		}
+		if err != nil {
+			return fmt.Errorf("iavl: decoding SnapshotIAVLItem: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewSnapshotIAVLItem converts an ExportNode into its wire representation. It is exported so that
+// callers composing their own stream framing around SnapshotIAVLItem, e.g. the snapshot
+// subpackage's multi-store manager, don't need to re-derive the field mapping.
+func NewSnapshotIAVLItem(node *ExportNode) *SnapshotIAVLItem {
+	return newSnapshotIAVLItem(node)
+}
+
+func newSnapshotIAVLItem(node *ExportNode) *SnapshotIAVLItem {
+	item := &SnapshotIAVLItem{
+		Key:     node.Key,
+		Value:   node.Value,
+		Version: node.NodeKey.version,
+		Height:  int32(node.Height),
+	}
+	if node.NodeKey.path != nil {
+		item.Path = node.NodeKey.path.Bytes()
+	}
+	return item
+}
+
+// ExportNode converts the wire item back into an ExportNode ready for Importer.Add.
+func (m *SnapshotIAVLItem) ExportNode() *ExportNode {
+	return m.toExportNode()
+}
+
+func (m *SnapshotIAVLItem) toExportNode() *ExportNode {
+	return &ExportNode{
+		Key:    m.Key,
+		Value:  m.Value,
+		Height: int8(m.Height),
+		NodeKey: &NodeKey{
+			version: m.Version,
+			path:    new(big.Int).SetBytes(m.Path),
+		},
+	}
+}
+
+// ImportStream reads length-prefixed SnapshotIAVLItem messages from r until EOF, feeding each one
+// into a new Importer for version via Add(), and commits once the stream is exhausted. This lets
+// callers plug an IAVL restore directly into a gRPC stream or file, e.g. a cosmos-sdk store/v2
+// snapshot manager, without materializing the full set of ExportNodes or driving Importer.Add in
+// a hand-rolled loop.
+func (tree *MutableTree) ImportStream(version int64, r protoio.Reader) error {
+	importer, err := tree.Import(version)
+	if err != nil {
+		return err
+	}
+	defer importer.Close()
+
+	item := &SnapshotIAVLItem{}
+	for {
+		item.Reset()
+		if _, err := r.ReadMsg(item); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to read snapshot item: %w", err)
+		}
+		if err := importer.Add(item.toExportNode()); err != nil {
+			return err
+		}
+	}
+
+	return importer.Commit()
+}
+
+// ExportStream drives an Exporter over the tree, writing each ExportNode to w as a length-prefixed
+// SnapshotIAVLItem message, and closes the Exporter once the stream is exhausted. It is the write
+// side of ImportStream, letting callers pipe a snapshot out over io.Writer without buffering
+// ExportNodes or calling Exporter.Next() themselves.
+func (tree *ImmutableTree) ExportStream(w protoio.Writer) error {
+	exporter, err := tree.Export()
+	if err != nil {
+		return err
+	}
+	defer exporter.Close()
+
+	for {
+		node, err := exporter.Next()
+		if err == ExportDone {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export node: %w", err)
+		}
+		if err := w.WriteMsg(newSnapshotIAVLItem(node)); err != nil {
+			return fmt.Errorf("failed to write snapshot item: %w", err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package iavl
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSnapshotIAVLItemMarshalRoundtrip(t *testing.T) {
+	item := &SnapshotIAVLItem{
+		Key:     []byte("key"),
+		Value:   []byte("value"),
+		Version: 7,
+		Height:  3,
+		Path:    big.NewInt(42).Bytes(),
+	}
+
+	data, err := item.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	got := &SnapshotIAVLItem{}
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if string(got.Key) != string(item.Key) || string(got.Value) != string(item.Value) ||
+		got.Version != item.Version || got.Height != item.Height || string(got.Path) != string(item.Path) {
+		t.Fatalf("roundtrip mismatch: got %+v, want %+v", got, item)
+	}
+}
+
+// TestSnapshotIAVLItemResetClearsStaleFields guards against ImportStream's reuse of a single
+// SnapshotIAVLItem across the whole read loop: Marshal omits zero-length bytes fields entirely, so
+// without a real Reset(), an item with an empty Value/Path following one with non-empty fields
+// would silently keep the previous message's bytes.
+func TestSnapshotIAVLItemResetClearsStaleFields(t *testing.T) {
+	item := &SnapshotIAVLItem{}
+
+	first := &SnapshotIAVLItem{Key: []byte("k1"), Value: []byte("v1"), Version: 1, Path: []byte{1}}
+	data, err := first.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	item.Reset()
+	if err := item.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	second := &SnapshotIAVLItem{Key: []byte("k2"), Version: 2}
+	data, err = second.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	item.Reset()
+	if err := item.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if len(item.Value) != 0 {
+		t.Fatalf("Value leaked across Reset: got %q, want empty", item.Value)
+	}
+	if len(item.Path) != 0 {
+		t.Fatalf("Path leaked across Reset: got %v, want empty", item.Path)
+	}
+}
+
+func TestDecodeBytesFieldTruncatedInput(t *testing.T) {
+	var buf []byte
+	buf = appendBytesField(buf, 1, []byte("hello"))
+
+	// Truncate the buffer so the declared length exceeds what's actually available.
+	truncated := buf[:len(buf)-2]
+
+	item := &SnapshotIAVLItem{}
+	if err := item.Unmarshal(truncated); err == nil {
+		t.Fatal("expected an error decoding a truncated bytes field, got nil")
+	}
+}
@@ -0,0 +1,42 @@
+package iavl
+
+import "bytes"
+
+// ImportOptions configures optional behavior for MutableTree.ImportWithOptions.
+type ImportOptions struct {
+	// BuildFastIndex, when true, has the importer additionally populate the fast-node index for
+	// every leaf ExportNode as it is added, in the same batch as the regular node write. Without
+	// it, iterating a freshly imported tree is slow until a background migration builds the fast
+	// index on first use.
+	BuildFastIndex bool
+}
+
+// ImportWithOptions creates an Importer like MutableTree.Import, but applies ImportOptions to it.
+func (tree *MutableTree) ImportWithOptions(version int64, options ImportOptions) (*Importer, error) {
+	importer, err := newImporter(tree, version)
+	if err != nil {
+		return nil, err
+	}
+	importer.buildFastIndex = options.BuildFastIndex
+	return importer, nil
+}
+
+// addFastNode writes a fast-node index entry for a leaf node into the same batch as the regular
+// node write, so that once Commit records the fast-index storage version, LoadVersion can skip
+// the usual post-import migration.
+func (i *Importer) addFastNode(node *Node, version int64) error {
+	fastNode := NewFastNode(node.key, node.value, version)
+
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufPool.Put(buf)
+
+	if err := fastNode.writeBytes(buf); err != nil {
+		return err
+	}
+
+	bytesCopy := make([]byte, buf.Len())
+	copy(bytesCopy, buf.Bytes())
+
+	return i.batch.Set(i.tree.ndb.fastNodeKey(node.key), bytesCopy)
+}
@@ -0,0 +1,61 @@
+package iavl
+
+// buildNode resolves exportNode's children from stack, hashes and validates the resulting Node,
+// and returns the stack the caller should adopt once the node has been durably written. It is the
+// core of Importer.Add, factored out so ParallelImporter's per-partition workers and final
+// stitching pass can build nodes the same way without going through a single shared stack.
+//
+// We build the tree from the bottom-left up. The stack is used to store unresolved left children
+// while constructing right children. When all children are built, the parent can be constructed
+// and the resolved children can be discarded from the stack. Using a stack ensures that we can
+// handle additional unresolved left children while building a right branch.
+//
+// The returned stack is only valid once the caller has also durably written node; buildNode itself
+// never mutates stack, so an error here leaves the caller's own stack untouched.
+func buildNode(stack []*Node, exportNode *ExportNode) (node *Node, newStack []*Node, err error) {
+	node = &Node{
+		key:           exportNode.Key,
+		value:         exportNode.Value,
+		nodeKey:       exportNode.NodeKey,
+		subtreeHeight: exportNode.Height,
+	}
+
+	stackSize := len(stack)
+	switch {
+	case stackSize >= 2 && stack[stackSize-1].subtreeHeight < node.subtreeHeight && stack[stackSize-2].subtreeHeight < node.subtreeHeight:
+		node.leftNode = stack[stackSize-2]
+		node.rightNode = stack[stackSize-1]
+	case stackSize >= 1 && stack[stackSize-1].subtreeHeight < node.subtreeHeight:
+		node.leftNode = stack[stackSize-1]
+	}
+
+	if node.subtreeHeight == 0 {
+		node.size = 1
+	}
+	if node.leftNode != nil {
+		node.size += node.leftNode.size
+		node.leftNodeKey = node.leftNode.nodeKey
+	}
+	if node.rightNode != nil {
+		node.size += node.rightNode.size
+		node.rightNodeKey = node.rightNode.nodeKey
+	}
+
+	if _, err := node._hash(exportNode.NodeKey.version); err != nil {
+		return nil, nil, err
+	}
+	if err := node.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case node.leftNode != nil && node.rightNode != nil:
+		stack = stack[:stackSize-2]
+	case node.leftNode != nil || node.rightNode != nil:
+		stack = stack[:stackSize-1]
+	}
+	// Only hash\height\size of the node will be used after it is pushed into the stack.
+	newStack = append(stack, &Node{hash: node.hash, subtreeHeight: node.subtreeHeight, size: node.size, nodeKey: node.nodeKey})
+
+	return node, newStack, nil
+}
@@ -0,0 +1,31 @@
+package iavl
+
+import "errors"
+
+// ErrRootMismatch is returned by Commit when the import was created via
+// MutableTree.ImportWithVerification and the finished tree's root hash does not match the
+// expected hash given at construction time.
+var ErrRootMismatch = errors.New("iavl: imported root hash does not match expected root hash")
+
+// ImportWithVerification creates an Importer like MutableTree.Import, but additionally tracks the
+// running root hash as nodes are added and has Commit assert it equals expectedRootHash before
+// writing anything visible. This lets callers doing chunked state sync compare a snapshot against
+// a trusted light-client header hash before committing a possibly corrupted snapshot to disk.
+func (tree *MutableTree) ImportWithVerification(version int64, expectedRootHash []byte) (*Importer, error) {
+	importer, err := newImporter(tree, version)
+	if err != nil {
+		return nil, err
+	}
+	importer.expectedRootHash = expectedRootHash
+	return importer, nil
+}
+
+// RootHash returns the hash of the root of the tree built so far. It is only meaningful once every
+// ExportNode has been added: callers doing chunked state sync can compare it against a trusted
+// light-client header hash before calling Commit, which writes WriteSync.
+func (i *Importer) RootHash() []byte {
+	if len(i.stack) != 1 {
+		return nil
+	}
+	return i.stack[0].hash
+}
@@ -0,0 +1,98 @@
+package iavl
+
+import (
+	"testing"
+
+	"cosmossdk.io/log"
+	db "github.com/cosmos/cosmos-db"
+)
+
+func TestImportWithVerificationRejectsMismatch(t *testing.T) {
+	src := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	if _, err := src.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	_, version, err := src.SaveVersion()
+	if err != nil {
+		t.Fatalf("SaveVersion failed: %v", err)
+	}
+	immutable, err := src.GetImmutable(version)
+	if err != nil {
+		t.Fatalf("GetImmutable failed: %v", err)
+	}
+
+	dst := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	importer, err := dst.ImportWithVerification(version, []byte("not-the-real-root-hash"))
+	if err != nil {
+		t.Fatalf("ImportWithVerification failed: %v", err)
+	}
+	defer importer.Close()
+
+	exporter, err := immutable.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	defer exporter.Close()
+
+	for {
+		node, err := exporter.Next()
+		if err == ExportDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if err := importer.Add(node); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	if err := importer.Commit(); err != ErrRootMismatch {
+		t.Fatalf("expected ErrRootMismatch, got %v", err)
+	}
+}
+
+func TestImportWithVerificationAcceptsMatch(t *testing.T) {
+	src := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	if _, err := src.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	_, version, err := src.SaveVersion()
+	if err != nil {
+		t.Fatalf("SaveVersion failed: %v", err)
+	}
+	immutable, err := src.GetImmutable(version)
+	if err != nil {
+		t.Fatalf("GetImmutable failed: %v", err)
+	}
+
+	dst := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	importer, err := dst.ImportWithVerification(version, immutable.Hash())
+	if err != nil {
+		t.Fatalf("ImportWithVerification failed: %v", err)
+	}
+	defer importer.Close()
+
+	exporter, err := immutable.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	defer exporter.Close()
+
+	for {
+		node, err := exporter.Next()
+		if err == ExportDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if err := importer.Add(node); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	if err := importer.Commit(); err != nil {
+		t.Fatalf("expected the matching root hash to be accepted, got: %v", err)
+	}
+}
@@ -0,0 +1,496 @@
+package iavl
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sync"
+
+	db "github.com/cosmos/cosmos-db"
+)
+
+// ParallelImporter imports data into an empty MutableTree the same way Importer does, but spreads
+// the nodes at or below splitHeight (a node height, counted up from the leaves at 0) across
+// independent partitions, one per maximal subtree whose root is at or below splitHeight. How many
+// partitions a tree yields depends on the tree's size, not on splitHeight alone. Hashing and writeBytes dominate import CPU, so farming the
+// depth-first post-order stream's partitions out across a pool of worker goroutines gives
+// near-linear speedup on multi-core restores of large state.
+//
+// It is created by MutableTree.ParallelImport(). Callers feed it with the same ExportNodes an
+// Exporter would produce for the tree, routing nodes at or below splitHeight to AddPartition
+// (calling FinishPartition once each partition's nodes are exhausted) and nodes above splitHeight
+// to AddTop, in the exact order Exporter would emit them - ImmutableTree.ParallelExport does this
+// automatically for a local, in-process export. Each
+// partition gets its own goroutine, but at most workers of them process nodes at once: a
+// partition's goroutine borrows a worker from a fixed-size pool for its whole lifetime, so fewer
+// workers than partitions is expected and simply means some workers process more than one
+// partition, one at a time. Commit() waits for every partition to finish, then single-threadedly
+// stitches their resolved subtree roots together with whatever was added via AddTop to build the
+// nodes above splitHeight.
+//
+// Users must call Close() when done, the same as Importer - whether or not Commit succeeded.
+type ParallelImporter struct {
+	tree        *MutableTree
+	version     int64
+	splitHeight int8
+	numWorkers  int
+
+	// pool holds the workers not currently assigned to a partition. A partition's goroutine
+	// borrows one for its entire lifetime and returns it when done, so at most numWorkers
+	// partitions are ever being processed concurrently.
+	pool chan *importWorker
+
+	mu         sync.Mutex
+	partitions map[int]*partitionJob
+	topEvents  []topEvent
+	wg         sync.WaitGroup
+	closed     bool
+}
+
+// importWorker owns one db.Batch, reused across every partition it is assigned over the
+// ParallelImporter's lifetime. Like Importer.Add, its batch is flushed once it reaches
+// maxBatchSize, so a worker's memory use stays bounded regardless of how many or how large the
+// partitions it processes are.
+type importWorker struct {
+	batch     db.Batch
+	batchSize uint32
+}
+
+// partitionJob is one partition's in-order stream of ExportNodes and the state its worker goroutine
+// resolves once the stream is exhausted.
+type partitionJob struct {
+	in        chan *ExportNode
+	closeOnce sync.Once
+
+	root *Node
+	err  error
+}
+
+// topEvent is one step of the deferred top-level stitch: either a genuine ExportNode above
+// splitHeight, or a marker that a partition's resolved root belongs at this position in the
+// stream.
+type topEvent struct {
+	node      *ExportNode
+	partition int
+}
+
+// ParallelImport creates a ParallelImporter for an empty MutableTree, backed by a pool of workers
+// batch-owning workers. Partitions - whose number depends on the tree's size - are scheduled onto
+// that pool as AddPartition introduces them, so workers need not equal, or even divide, the number of
+// partitions actually used.
+func (tree *MutableTree) ParallelImport(version int64, workers int, splitHeight int8) (*ParallelImporter, error) {
+	if version < 0 {
+		return nil, fmt.Errorf("imported version cannot be negative")
+	}
+	if tree.ndb.latestVersion > 0 {
+		return nil, fmt.Errorf("found database at version %d, must be 0", tree.ndb.latestVersion)
+	}
+	if !tree.IsEmpty() {
+		return nil, fmt.Errorf("tree must be empty")
+	}
+	if workers < 1 {
+		return nil, fmt.Errorf("workers must be at least 1, got %d", workers)
+	}
+	if splitHeight < 1 || splitHeight > 62 {
+		return nil, fmt.Errorf("splitHeight must be between 1 and 62, got %d", splitHeight)
+	}
+
+	pi := &ParallelImporter{
+		tree:        tree,
+		version:     version,
+		splitHeight: splitHeight,
+		numWorkers:  workers,
+		pool:        make(chan *importWorker, workers),
+		partitions:  make(map[int]*partitionJob),
+	}
+	for n := 0; n < workers; n++ {
+		pi.pool <- &importWorker{batch: tree.ndb.db.NewBatch()}
+	}
+
+	return pi, nil
+}
+
+// partitionJobFor returns idx's partitionJob, creating it and starting its goroutine on first use.
+func (pi *ParallelImporter) partitionJobFor(idx int) (*partitionJob, error) {
+	if idx < 0 {
+		return nil, fmt.Errorf("partition %d must not be negative", idx)
+	}
+
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	job, ok := pi.partitions[idx]
+	if !ok {
+		job = &partitionJob{in: make(chan *ExportNode, maxBatchSize)}
+		pi.partitions[idx] = job
+		pi.wg.Add(1)
+		go pi.runPartition(idx, job)
+	}
+	return job, nil
+}
+
+// runPartition borrows a worker from the pool for idx's entire lifetime, processes every node sent
+// to job.in in order, and resolves job.root/job.err once the channel is closed - by
+// FinishPartition(idx), or by Close or Commit for a partition left unfinished.
+func (pi *ParallelImporter) runPartition(idx int, job *partitionJob) {
+	defer pi.wg.Done()
+
+	w := <-pi.pool
+	defer func() { pi.pool <- w }()
+
+	stack := make([]*Node, 0, 8)
+	for exportNode := range job.in {
+		if job.err != nil {
+			continue // drain the channel without processing so AddPartition never blocks
+		}
+		newStack, err := pi.addToWorker(w, stack, exportNode)
+		if err != nil {
+			job.err = err
+			continue
+		}
+		stack = newStack
+	}
+
+	if job.err != nil {
+		return
+	}
+	if len(stack) != 1 {
+		job.err = fmt.Errorf("partition %d: invalid node structure, found stack size %d", idx, len(stack))
+		return
+	}
+	job.root = stack[0]
+}
+
+func (pi *ParallelImporter) addToWorker(w *importWorker, stack []*Node, exportNode *ExportNode) ([]*Node, error) {
+	node, newStack, err := buildNode(stack, exportNode)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufPool.Put(buf)
+
+	if err := node.writeBytes(buf); err != nil {
+		return nil, err
+	}
+	bytesCopy := make([]byte, buf.Len())
+	copy(bytesCopy, buf.Bytes())
+
+	if err := w.batch.Set(pi.tree.ndb.nodeKey(node.nodeKey), bytesCopy); err != nil {
+		return nil, err
+	}
+
+	w.batchSize++
+	if w.batchSize >= maxBatchSize {
+		if err := w.batch.Write(); err != nil {
+			return nil, err
+		}
+		w.batch.Close()
+		w.batch = pi.tree.ndb.db.NewBatch()
+		w.batchSize = 0
+	}
+
+	return newStack, nil
+}
+
+// AddPartition routes an ExportNode at or below splitHeight to partition idx. Within a single idx,
+// nodes must be added in the same depth-first post-order an Exporter would produce for that
+// subtree. The caller must call FinishPartition(idx) exactly once, after the last node for idx has
+// been added, to free idx's worker and record its position in the top-level stitch.
+//
+// idx's subtree root is not necessarily a node with Height == splitHeight: the AVL balance
+// invariant allows a node one level above splitHeight to have one child at splitHeight and the
+// other at splitHeight-1, so a partition's root can be any height at or below splitHeight. Callers
+// that can't derive partition boundaries themselves should drive this via
+// ImmutableTree.ParallelExport, which does.
+func (pi *ParallelImporter) AddPartition(idx int, node *ExportNode) error {
+	if node == nil {
+		return fmt.Errorf("node cannot be nil")
+	}
+
+	job, err := pi.partitionJobFor(idx)
+	if err != nil {
+		return err
+	}
+
+	job.in <- node
+
+	return nil
+}
+
+// FinishPartition marks partition idx as complete: no more nodes will be added to it. It frees
+// idx's worker for another partition and records idx's position in the top-level stitch, in the
+// order FinishPartition calls are made across all partitions and AddTop calls.
+func (pi *ParallelImporter) FinishPartition(idx int) error {
+	job, err := pi.partitionJobFor(idx)
+	if err != nil {
+		return err
+	}
+
+	pi.mu.Lock()
+	pi.topEvents = append(pi.topEvents, topEvent{partition: idx})
+	pi.mu.Unlock()
+	job.closeOnce.Do(func() { close(job.in) })
+
+	return nil
+}
+
+// AddTop routes an ExportNode above splitHeight into the final single-threaded stitching pass.
+// Nodes must be added in the same depth-first post-order an Exporter would produce for the whole
+// tree, interleaved with the AddPartition calls that produced each subtree below them.
+func (pi *ParallelImporter) AddTop(node *ExportNode) error {
+	if node == nil {
+		return fmt.Errorf("node cannot be nil")
+	}
+	if node.Height <= pi.splitHeight {
+		return fmt.Errorf("node height %d is not above splitHeight %d, use AddPartition", node.Height, pi.splitHeight)
+	}
+
+	pi.mu.Lock()
+	pi.topEvents = append(pi.topEvents, topEvent{node: node})
+	pi.mu.Unlock()
+
+	return nil
+}
+
+// finishPartitions closes every partition's input channel that FinishPartition hasn't already closed,
+// so Close or Commit can safely wait on pi.wg even if the caller abandoned the import mid-partition.
+func (pi *ParallelImporter) finishPartitions() {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+	for _, job := range pi.partitions {
+		job.closeOnce.Do(func() { close(job.in) })
+	}
+}
+
+// Close frees all resources without committing, aborting any partitions still in progress. It is
+// safe to call multiple times, and safe to call after a failed or successful Commit.
+func (pi *ParallelImporter) Close() {
+	pi.mu.Lock()
+	if pi.closed {
+		pi.mu.Unlock()
+		return
+	}
+	pi.closed = true
+	pi.mu.Unlock()
+
+	pi.finishPartitions()
+	pi.wg.Wait()
+
+	close(pi.pool)
+	for w := range pi.pool {
+		w.batch.Close()
+	}
+}
+
+// Commit closes every partition's input channel, waits for all partitions to finish, then replays
+// the recorded top-level events through the ordinary single-stack algorithm - substituting each
+// partition's resolved root where its FinishPartition call occurred among the AddTop calls - to
+// build the nodes above splitHeight. It finalizes the import the same way Importer.Commit does, and
+// calls Close() internally so workers' batches are cleaned up on every return path.
+func (pi *ParallelImporter) Commit() error {
+	if pi.closed {
+		return ErrNoImport
+	}
+	defer pi.Close()
+
+	pi.finishPartitions()
+	pi.wg.Wait()
+
+	topBatch := pi.tree.ndb.db.NewBatch()
+	defer topBatch.Close()
+
+	stack := make([]*Node, 0, 8)
+	for _, ev := range pi.topEvents {
+		if ev.node == nil {
+			job := pi.partitions[ev.partition]
+			if job.err != nil {
+				return fmt.Errorf("partition %d: %w", ev.partition, job.err)
+			}
+			stack = append(stack, job.root)
+			continue
+		}
+
+		node, newStack, err := buildNode(stack, ev.node)
+		if err != nil {
+			return err
+		}
+
+		buf := bufPool.Get().(*bytes.Buffer)
+		buf.Reset()
+		if err := node.writeBytes(buf); err != nil {
+			bufPool.Put(buf)
+			return err
+		}
+		bytesCopy := make([]byte, buf.Len())
+		copy(bytesCopy, buf.Bytes())
+		bufPool.Put(buf)
+
+		if err := topBatch.Set(pi.tree.ndb.nodeKey(node.nodeKey), bytesCopy); err != nil {
+			return err
+		}
+
+		stack = newStack
+	}
+
+	rootKey := &NodeKey{version: pi.version, path: big.NewInt(1)}
+	switch len(stack) {
+	case 0:
+		if err := topBatch.Set(pi.tree.ndb.nodeKey(rootKey), []byte{}); err != nil {
+			return err
+		}
+	case 1:
+	default:
+		return fmt.Errorf("invalid node structure, found stack size %d when committing", len(stack))
+	}
+
+	// Flush every worker's outstanding batch before the top batch goes in, so a crash can never
+	// observe the top-level write without the subtree writes it depends on. wg.Wait() above
+	// guarantees every worker has already been returned to the pool, so this drains exactly
+	// numWorkers items and puts each back for Close to clean up afterwards.
+	for n := 0; n < pi.numWorkers; n++ {
+		w := <-pi.pool
+		err := w.batch.Write()
+		pi.pool <- w
+		if err != nil {
+			return err
+		}
+	}
+	if err := topBatch.WriteSync(); err != nil {
+		return err
+	}
+
+	pi.tree.ndb.resetLatestVersion(pi.version)
+	if _, err := pi.tree.LoadVersion(pi.version); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// exportShadowEntry mirrors one slot of buildNode's stack-reduction algorithm during
+// ParallelExport, tracking just enough to classify nodes correctly: the height the slot currently
+// represents, and - for a slot at or below splitHeight, whose partition assignment isn't final
+// yet - the ExportNodes buffered for it so far. A slot above splitHeight has already been sent to
+// AddTop, so it carries no buffered nodes.
+type exportShadowEntry struct {
+	height int8
+	nodes  []*ExportNode
+}
+
+// ParallelExport drives an Exporter over tree and feeds the resulting ExportNodes into dst via
+// AddPartition/FinishPartition/AddTop, classifying each node by the same splitHeight boundary dst
+// was created with. This lets a caller doing a local, in-process parallel restore use
+// ParallelImporter without re-deriving that partition assignment itself.
+//
+// A node belongs to a partition if its own height is at or below splitHeight: the AVL balance
+// invariant guarantees every descendant of such a node is also at or below splitHeight, so the
+// whole subtree rooted there is self-contained. But that root is not necessarily at exactly
+// splitHeight - an ancestor one level above splitHeight can have one child at splitHeight and the
+// other at splitHeight-1 - so a partition's conclusion can't be detected by comparing a single
+// node's height against splitHeight. Instead this replays the same left/right stack reduction
+// buildNode uses to reassemble a tree from ExportNodes, but only on heights, to find exactly which
+// already-buffered nodes make up one disjoint subtree: every buffered group on the stack is
+// finalized - dispatched to AddPartition and closed with FinishPartition, in stream order - the
+// moment a node above splitHeight arrives, since each such group's parent is then above
+// splitHeight too, or when the export stream ends while it's still unconsumed.
+//
+// This only covers in-process use. A caller restoring from a remote stream (e.g. gRPC or a file)
+// still needs the partition boundary encoded on the wire, since SnapshotIAVLItem carries no
+// partition index; that requires extending the wire format itself and is not done here.
+func (tree *ImmutableTree) ParallelExport(dst *ParallelImporter, splitHeight int8) error {
+	exporter, err := tree.Export()
+	if err != nil {
+		return err
+	}
+	defer exporter.Close()
+
+	partition := 0
+	dispatch := func(nodes []*ExportNode) error {
+		idx := partition
+		partition++
+		for _, n := range nodes {
+			if err := dst.AddPartition(idx, n); err != nil {
+				return err
+			}
+		}
+		return dst.FinishPartition(idx)
+	}
+
+	var stack []exportShadowEntry
+	for {
+		node, err := exporter.Next()
+		if err == ExportDone {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export node: %w", err)
+		}
+
+		size := len(stack)
+		var consumed []exportShadowEntry
+		switch {
+		case size >= 2 && stack[size-1].height < node.Height && stack[size-2].height < node.Height:
+			consumed = stack[size-2:]
+			stack = stack[:size-2]
+		case size >= 1 && stack[size-1].height < node.Height:
+			consumed = stack[size-1:]
+			stack = stack[:size-1]
+		}
+
+		if node.Height > splitHeight {
+			// A buffered entry still on the stack is a left child whose right sibling subtree
+			// holds node, so its parent is above splitHeight: finalize it now, ahead of the
+			// consumed entries, so partitions reach the stitch in stream order.
+			for idx := range stack {
+				if len(stack[idx].nodes) == 0 {
+					continue
+				}
+				if err := dispatch(stack[idx].nodes); err != nil {
+					return err
+				}
+				stack[idx].nodes = nil
+			}
+			// Every consumed entry with buffered nodes is a disjoint below-boundary subtree that
+			// node, being above splitHeight, has just become the first ancestor of - finalize each
+			// one, in order, before node itself goes to AddTop.
+			for _, entry := range consumed {
+				if len(entry.nodes) == 0 {
+					continue // already an above-splitHeight entry sent straight to AddTop
+				}
+				if err := dispatch(entry.nodes); err != nil {
+					return err
+				}
+			}
+			if err := dst.AddTop(node); err != nil {
+				return err
+			}
+			stack = append(stack, exportShadowEntry{height: node.Height})
+			continue
+		}
+
+		// node is at or below splitHeight: whatever it consumed is, by the AVL invariant, also at
+		// or below splitHeight, so this just extends the same still-open subtree rather than
+		// concluding anything.
+		var nodes []*ExportNode
+		for _, entry := range consumed {
+			nodes = append(nodes, entry.nodes...)
+		}
+		nodes = append(nodes, node)
+		stack = append(stack, exportShadowEntry{height: node.Height, nodes: nodes})
+	}
+
+	// Anything still pending here was never consumed by a node above splitHeight: the whole tree -
+	// or whatever top-level piece remains - never crossed the boundary.
+	for _, entry := range stack {
+		if len(entry.nodes) == 0 {
+			continue
+		}
+		if err := dispatch(entry.nodes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
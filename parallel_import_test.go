@@ -0,0 +1,167 @@
+package iavl
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"cosmossdk.io/log"
+	db "github.com/cosmos/cosmos-db"
+)
+
+func newParallelImportTestTree(t *testing.T, numKeys int) (*MutableTree, int64) {
+	t.Helper()
+	tree := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	for i := 0; i < numKeys; i++ {
+		key := []byte(fmt.Sprintf("key-%04d", i))
+		value := []byte(fmt.Sprintf("value-%04d", i))
+		if _, err := tree.Set(key, value); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	_, version, err := tree.SaveVersion()
+	if err != nil {
+		t.Fatalf("SaveVersion failed: %v", err)
+	}
+	return tree, version
+}
+
+// TestParallelImporterMatchesSequentialImport covers the request's own stated goal - a parallel
+// import producing the same tree a sequential import would - using fewer workers than partitions,
+// so the single worker has to process every partition in turn.
+func TestParallelImporterMatchesSequentialImport(t *testing.T) {
+	src, version := newParallelImportTestTree(t, 16)
+	immutable, err := src.GetImmutable(version)
+	if err != nil {
+		t.Fatalf("GetImmutable failed: %v", err)
+	}
+	wantHash := immutable.Hash()
+
+	dst := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	const splitHeight = int8(1)
+	const workers = 1 // fewer than the partitions: at splitHeight 1 each holds at most 2 of the 16 leaves
+	pi, err := dst.ParallelImport(version, workers, splitHeight)
+	if err != nil {
+		t.Fatalf("ParallelImport failed: %v", err)
+	}
+	defer pi.Close()
+
+	if err := immutable.ParallelExport(pi, splitHeight); err != nil {
+		t.Fatalf("ParallelExport failed: %v", err)
+	}
+	if err := pi.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	gotImmutable, err := dst.GetImmutable(version)
+	if err != nil {
+		t.Fatalf("GetImmutable on imported tree failed: %v", err)
+	}
+	if !bytes.Equal(gotImmutable.Hash(), wantHash) {
+		t.Fatalf("parallel import hash %x does not match source hash %x", gotImmutable.Hash(), wantHash)
+	}
+}
+
+// TestParallelImporterMatchesSequentialImportUnbalancedSplit covers the scenario sequential-key
+// inserts miss: a tree shape with unequal sibling heights straddling splitHeight, so at least one
+// partition's real subtree root sits strictly below splitHeight rather than exactly on it. Deleting
+// a scattered subset of keys after inserting them in a shuffled order is enough to make the AVL
+// rebalancing produce siblings whose heights differ by one, which a height == splitHeight boundary
+// check would misclassify.
+func TestParallelImporterMatchesSequentialImportUnbalancedSplit(t *testing.T) {
+	src := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+
+	// A fixed, non-sorted insertion order (bit-reversal of 0..63) so the tree isn't the perfectly
+	// balanced shape sequential inserts happen to produce.
+	const n = 64
+	order := make([]int, n)
+	for i := 0; i < n; i++ {
+		rev := 0
+		for b := 0; b < 6; b++ {
+			if i&(1<<uint(b)) != 0 {
+				rev |= 1 << uint(5-b)
+			}
+		}
+		order[i] = rev
+	}
+	for _, i := range order {
+		key := []byte(fmt.Sprintf("key-%04d", i))
+		value := []byte(fmt.Sprintf("value-%04d", i))
+		if _, err := src.Set(key, value); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+	// Deleting a scattered subset introduces exactly the kind of local imbalance a uniform
+	// insertion order wouldn't: some subtrees lose a leaf and shrink by one level, others don't.
+	for i := 0; i < n; i += 3 {
+		key := []byte(fmt.Sprintf("key-%04d", i))
+		if _, _, err := src.Remove(key); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+	}
+	_, version, err := src.SaveVersion()
+	if err != nil {
+		t.Fatalf("SaveVersion failed: %v", err)
+	}
+
+	immutable, err := src.GetImmutable(version)
+	if err != nil {
+		t.Fatalf("GetImmutable failed: %v", err)
+	}
+	wantHash := immutable.Hash()
+
+	dst := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	const splitHeight = int8(2)
+	const workers = 2
+	pi, err := dst.ParallelImport(version, workers, splitHeight)
+	if err != nil {
+		t.Fatalf("ParallelImport failed: %v", err)
+	}
+	defer pi.Close()
+
+	if err := immutable.ParallelExport(pi, splitHeight); err != nil {
+		t.Fatalf("ParallelExport failed: %v", err)
+	}
+	if err := pi.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	gotImmutable, err := dst.GetImmutable(version)
+	if err != nil {
+		t.Fatalf("GetImmutable on imported tree failed: %v", err)
+	}
+	if !bytes.Equal(gotImmutable.Hash(), wantHash) {
+		t.Fatalf("parallel import hash %x does not match source hash %x", gotImmutable.Hash(), wantHash)
+	}
+}
+
+// TestParallelImporterCloseWithoutCommit ensures an abandoned ParallelImporter's Close doesn't
+// block or panic, even with a partition still open - the goroutine/batch leak the request flagged.
+func TestParallelImporterCloseWithoutCommit(t *testing.T) {
+	dst := NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	pi, err := dst.ParallelImport(1, 2, 1)
+	if err != nil {
+		t.Fatalf("ParallelImport failed: %v", err)
+	}
+
+	// Start a partition but never call FinishPartition for it, simulating an abandoned import.
+	node := &ExportNode{Key: []byte("k"), Value: []byte("v"), Height: 0, NodeKey: &NodeKey{version: 1, path: big.NewInt(1)}}
+	if err := pi.AddPartition(0, node); err != nil {
+		t.Fatalf("AddPartition failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pi.Close()
+		pi.Close() // must also be safe to call twice
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return for an abandoned partition - goroutine leak")
+	}
+}
@@ -0,0 +1,63 @@
+package iavl
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The helpers below implement just enough of the protobuf wire format (varints and
+// length-delimited records) to (de)serialize SnapshotIAVLItem without pulling in a full
+// generated-code dependency for a single hand-maintained message.
+
+func appendVarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	buf = appendVarint(buf, uint64(field)<<3)
+	return appendVarint(buf, v)
+}
+
+func appendBytesField(buf []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return buf
+	}
+	buf = appendVarint(buf, uint64(field)<<3|2)
+	buf = appendVarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
+
+// decodeTag reads a field tag varint from the front of data. It returns an error, rather than
+// panicking, on truncated or malformed (overflowing) input, since this decodes bytes read off the
+// wire or a file.
+func decodeTag(data []byte) (field int, wireType int, n int, err error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, 0, fmt.Errorf("iavl: invalid tag varint")
+	}
+	return int(v >> 3), int(v & 0x7), n, nil
+}
+
+func decodeVarintField(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("iavl: invalid varint field")
+	}
+	return v, data[n:], nil
+}
+
+func decodeBytesField(data []byte) ([]byte, []byte, error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("iavl: invalid bytes field length")
+	}
+	data = data[n:]
+	if length > uint64(len(data)) {
+		return nil, nil, fmt.Errorf("iavl: truncated bytes field: want %d bytes, have %d", length, len(data))
+	}
+	out := make([]byte, length)
+	copy(out, data[:length])
+	return out, data[length:], nil
+}
@@ -0,0 +1,175 @@
+// Package snapshot manages IAVL snapshots spanning multiple stores, mirroring the shape of
+// cosmos-sdk store/v2's rootmulti snapshot manager: a single chunked stream interleaves per-store
+// headers with that store's own Exporter/Importer stream, so callers don't have to re-implement
+// the per-store stitching on top of the plain Importer/Exporter pair.
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cosmos/iavl"
+)
+
+// SnapshotStoreItem marks the start of a new store's node stream within a multi-store snapshot.
+type SnapshotStoreItem struct {
+	Name string
+}
+
+// Chunk is one unit of a Manager snapshot stream: either a SnapshotStoreItem header announcing
+// the store whose nodes follow, or a single node belonging to the most recently announced store.
+// Err is set instead of Store/Node when producing or consuming the stream failed.
+type Chunk struct {
+	Store *SnapshotStoreItem
+	Node  *iavl.SnapshotIAVLItem
+	Err   error
+}
+
+// Manager produces and consumes a single chunked snapshot stream spanning a collection of IAVL
+// trees keyed by store name.
+type Manager struct {
+	stores map[string]*iavl.MutableTree
+}
+
+// NewManager returns an empty Manager. Stores must be registered with RegisterStore before Create
+// or Restore is called.
+func NewManager() *Manager {
+	return &Manager{stores: make(map[string]*iavl.MutableTree)}
+}
+
+// RegisterStore adds or replaces the tree backing the named store.
+func (m *Manager) RegisterStore(name string, tree *iavl.MutableTree) {
+	m.stores[name] = tree
+}
+
+func (m *Manager) sortedNames() []string {
+	names := make([]string, 0, len(m.stores))
+	for name := range m.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create exports every registered store at version, in deterministic (lexicographic) store name
+// order, as a stream of Chunks: a SnapshotStoreItem header followed by that store's nodes. The
+// returned channel is closed once the stream, or a terminal error, has been fully sent.
+func (m *Manager) Create(version int64) (<-chan Chunk, error) {
+	if len(m.stores) == 0 {
+		return nil, fmt.Errorf("snapshot: no stores registered")
+	}
+
+	ch := make(chan Chunk)
+	go func() {
+		defer close(ch)
+		for _, name := range m.sortedNames() {
+			if err := m.exportStore(version, name, ch); err != nil {
+				ch <- Chunk{Err: err}
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (m *Manager) exportStore(version int64, name string, ch chan<- Chunk) error {
+	tree := m.stores[name]
+	immutable, err := tree.GetImmutable(version)
+	if err != nil {
+		return fmt.Errorf("store %q: %w", name, err)
+	}
+
+	exporter, err := immutable.Export()
+	if err != nil {
+		return fmt.Errorf("store %q: %w", name, err)
+	}
+	defer exporter.Close()
+
+	ch <- Chunk{Store: &SnapshotStoreItem{Name: name}}
+
+	for {
+		node, err := exporter.Next()
+		if err == iavl.ExportDone {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("store %q: %w", name, err)
+		}
+		ch <- Chunk{Node: iavl.NewSnapshotIAVLItem(node)}
+	}
+}
+
+// Restore consumes a Manager snapshot stream produced by Create, demultiplexing items by store
+// header and replaying each store's nodes into a fresh Importer for version. Every store must
+// finish adding its nodes without error before any of them are committed: if the stream breaks or
+// any store's Add fails, no importer is committed and every store is left as it started (version
+// 0, with whatever uncommitted nodes were already flushed to disk but not yet visible).
+//
+// Once the stream is exhausted, every store is Verified before any of them is Committed, in
+// deterministic (lexicographic) store name order: if any store's import is malformed, Restore
+// returns an error without committing any store, so a restore never leaves some stores visible at
+// the new version and others still at version 0. This can't make the final Commit of each store a
+// single cross-database transaction - each store is backed by its own db - so it only protects
+// against structural/hash failures caught by Verify; an I/O error during one store's Commit can
+// still leave stores committed before it visible and stores after it not.
+func (m *Manager) Restore(version int64, chunks <-chan Chunk) error {
+	importers := make(map[string]*iavl.Importer)
+	defer func() {
+		for _, importer := range importers {
+			importer.Close()
+		}
+	}()
+
+	var current *iavl.Importer
+	for chunk := range chunks {
+		switch {
+		case chunk.Err != nil:
+			return chunk.Err
+
+		case chunk.Store != nil:
+			tree, ok := m.stores[chunk.Store.Name]
+			if !ok {
+				return fmt.Errorf("snapshot: unregistered store %q in stream", chunk.Store.Name)
+			}
+			importer, err := tree.Import(version)
+			if err != nil {
+				return fmt.Errorf("store %q: %w", chunk.Store.Name, err)
+			}
+			importers[chunk.Store.Name] = importer
+			current = importer
+
+		case chunk.Node != nil:
+			if current == nil {
+				return fmt.Errorf("snapshot: node chunk received before any store header")
+			}
+			if err := current.Add(chunk.Node.ExportNode()); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("snapshot: empty chunk in stream")
+		}
+	}
+
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := importers[name].Verify(); err != nil {
+			return fmt.Errorf("store %q: %w", name, err)
+		}
+	}
+
+	for _, name := range names {
+		if err := importers[name].Commit(); err != nil {
+			return fmt.Errorf("store %q: %w", name, err)
+		}
+		delete(importers, name)
+	}
+
+	return nil
+}
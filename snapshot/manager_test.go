@@ -0,0 +1,91 @@
+package snapshot
+
+import (
+	"testing"
+
+	"cosmossdk.io/log"
+	db "github.com/cosmos/cosmos-db"
+	"github.com/cosmos/iavl"
+)
+
+func newTestTree(t *testing.T) *iavl.MutableTree {
+	t.Helper()
+	tree := iavl.NewMutableTree(db.NewMemDB(), 0, false, log.NewNopLogger())
+	return tree
+}
+
+// TestRestoreAtomicAcrossStores covers the atomicity the request asked for: if any one store's
+// import turns out to be malformed, Restore must not leave any store - including ones that were
+// individually well-formed - committed at the new version.
+func TestRestoreAtomicAcrossStores(t *testing.T) {
+	srcA := newTestTree(t)
+	if _, err := srcA.Set([]byte("a-key"), []byte("a-value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, _, err := srcA.SaveVersion(); err != nil {
+		t.Fatalf("SaveVersion failed: %v", err)
+	}
+
+	srcB := newTestTree(t)
+	if _, err := srcB.Set([]byte("b-key"), []byte("b-value")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, _, err := srcB.SaveVersion(); err != nil {
+		t.Fatalf("SaveVersion failed: %v", err)
+	}
+
+	create := NewManager()
+	create.RegisterStore("a", srcA)
+	create.RegisterStore("b", srcB)
+
+	created, err := create.Create(1)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	var chunks []Chunk
+	for chunk := range created {
+		chunks = append(chunks, chunk)
+	}
+
+	// Corrupt store "b"'s stream by duplicating its final node, so its importer ends up with two
+	// unresolved stack entries instead of a single root - a well-formed-looking stream for store
+	// "a" followed by a malformed one for store "b".
+	lastNodeIdx := -1
+	for idx, chunk := range chunks {
+		if chunk.Node != nil {
+			lastNodeIdx = idx
+		}
+	}
+	if lastNodeIdx == -1 {
+		t.Fatal("expected at least one node chunk in the created stream")
+	}
+	duplicated := make([]Chunk, 0, len(chunks)+1)
+	duplicated = append(duplicated, chunks[:lastNodeIdx+1]...)
+	duplicated = append(duplicated, chunks[lastNodeIdx])
+	duplicated = append(duplicated, chunks[lastNodeIdx+1:]...)
+
+	restoreA := newTestTree(t)
+	restoreB := newTestTree(t)
+	restore := NewManager()
+	restore.RegisterStore("a", restoreA)
+	restore.RegisterStore("b", restoreB)
+
+	replay := make(chan Chunk, len(duplicated))
+	for _, chunk := range duplicated {
+		replay <- chunk
+	}
+	close(replay)
+
+	if err := restore.Restore(1, replay); err == nil {
+		t.Fatal("expected Restore to fail on the malformed store, got nil")
+	}
+
+	// Neither store should have been left committed: Import succeeding again means the tree is
+	// still at version 0, exactly as if Restore had never run.
+	if _, err := restoreA.Import(1); err != nil {
+		t.Fatalf("store %q was left committed after a failed Restore: %v", "a", err)
+	}
+	if _, err := restoreB.Import(1); err != nil {
+		t.Fatalf("store %q was left committed after a failed Restore: %v", "b", err)
+	}
+}